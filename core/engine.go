@@ -0,0 +1,91 @@
+package core
+
+import (
+	"fmt"
+
+	ethconsensus "github.com/ethereum/go-ethereum/consensus"
+	ethclique "github.com/ethereum/go-ethereum/consensus/clique"
+	ethethash "github.com/ethereum/go-ethereum/consensus/ethash"
+	ethcore "github.com/ethereum/go-ethereum/core"
+	ethdb "github.com/ethereum/go-ethereum/ethdb"
+	ethparams "github.com/ethereum/go-ethereum/params"
+)
+
+// Chain names one of the networks NewEngine/ChainConfigFor know how to
+// set up, selected by the importer's --chain flag.
+type Chain string
+
+const (
+	ChainMainnet Chain = "mainnet"
+	ChainRopsten Chain = "ropsten"
+	ChainRinkeby Chain = "rinkeby"
+	ChainGoerli  Chain = "goerli"
+)
+
+// ParseChain validates name against the chains NewEngine supports.
+func ParseChain(name string) (Chain, error) {
+	switch Chain(name) {
+	case ChainMainnet, ChainRopsten, ChainRinkeby, ChainGoerli:
+		return Chain(name), nil
+	default:
+		return "", fmt.Errorf("unknown chain %q (want one of mainnet, ropsten, rinkeby, goerli)", name)
+	}
+}
+
+// ChainConfigFor returns the go-ethereum ChainConfig for chain, the same
+// ones a full node would load for it.
+func ChainConfigFor(chain Chain) (*ethparams.ChainConfig, error) {
+	switch chain {
+	case ChainMainnet:
+		return ethparams.MainnetChainConfig, nil
+	case ChainRopsten:
+		return ethparams.TestnetChainConfig, nil
+	case ChainRinkeby:
+		return ethparams.RinkebyChainConfig, nil
+	case ChainGoerli:
+		return ethparams.GoerliChainConfig, nil
+	default:
+		return nil, fmt.Errorf("unknown chain %q", chain)
+	}
+}
+
+// GenesisFor returns the go-ethereum genesis block (config, alloc and
+// header fields) for chain, the same one a full node would seed its
+// state from before importing a non-mainnet export.
+func GenesisFor(chain Chain) (*ethcore.Genesis, error) {
+	switch chain {
+	case ChainMainnet:
+		return ethcore.DefaultGenesisBlock(), nil
+	case ChainRopsten:
+		return ethcore.DefaultTestnetGenesisBlock(), nil
+	case ChainRinkeby:
+		return ethcore.DefaultRinkebyGenesisBlock(), nil
+	case ChainGoerli:
+		return ethcore.DefaultGoerliGenesisBlock(), nil
+	default:
+		return nil, fmt.Errorf("unknown chain %q", chain)
+	}
+}
+
+// NewEngine returns the consensus engine matching chain: Ethash (in faker
+// mode, since the importer replays already-final history and has no use
+// for real PoW/DAG verification) for the proof-of-work networks, Clique
+// for the proof-of-authority testnets. db backs Clique's vote/snapshot
+// storage; it may be nil for Ethash chains.
+func NewEngine(chain Chain, db ethdb.Database) (ethconsensus.Engine, error) {
+	switch chain {
+	case ChainMainnet, ChainRopsten:
+		return ethethash.NewFullFaker(), nil
+	case ChainRinkeby, ChainGoerli:
+		config, err := ChainConfigFor(chain)
+		if err != nil {
+			return nil, err
+		}
+		if config.Clique == nil {
+			return nil, fmt.Errorf("chain %q has no clique config", chain)
+		}
+		return ethclique.New(config.Clique, db), nil
+	default:
+		return nil, fmt.Errorf("unknown chain %q", chain)
+	}
+}
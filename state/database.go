@@ -0,0 +1,660 @@
+// Package state implements the go-ethereum state.Database and state.Trie
+// interfaces on top of a Cosmos SDK CommitMultiStore, so the EVM can read
+// and write Ethereum account/storage state that is actually persisted and
+// versioned through IAVL rather than go-ethereum's own trie/leveldb stack.
+package state
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	abci "github.com/tendermint/abci/types"
+	"github.com/tendermint/go-amino"
+	dbm "github.com/tendermint/tmlibs/db"
+
+	"github.com/cosmos/cosmos-sdk/store"
+	sdk "github.com/cosmos/cosmos-sdk/types"
+
+	"github.com/cosmos/ethermint/state/ethdb"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethstate "github.com/ethereum/go-ethereum/core/state"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	ethethdb "github.com/ethereum/go-ethereum/ethdb"
+	ethtrie "github.com/ethereum/go-ethereum/trie"
+)
+
+var (
+	// AccountsKey is the sub-store holding Ethereum accounts (balance,
+	// nonce, storage root hash, code hash).
+	AccountsKey = sdk.NewKVStoreKey("account")
+	// StorageKey is the sub-store holding contract storage (the effects
+	// of the SSTORE instruction).
+	StorageKey = sdk.NewKVStoreKey("storage")
+	// CodeKey is the sub-store holding contract code.
+	CodeKey = sdk.NewKVStoreKey("code")
+
+	accountsStoreName = AccountsKey.Name()
+	storageStoreName  = StorageKey.Name()
+)
+
+// lookupValue is what's stored in lookupDb: the IAVL version a given trie
+// root hash corresponds to. Entries indexed by block height (see
+// heightKey) also carry the account trie's root as of that height, so a
+// historical replay (e.g. debug_traceTransaction) can reopen exactly the
+// state a block saw without needing its own header index.
+type lookupValue struct {
+	VersionID int64
+	Root      ethcommon.Hash
+}
+
+// Database implements the go-ethereum state.Database interface. It was
+// previously named OurDatabase and lived in package main; it moved here so
+// the importer (and anything else outside main) can depend on it.
+type Database struct {
+	// stateStore holds the versioned history of accounts and contract
+	// storage. It is nil on a Database returned by OpenHistoricalDatabase,
+	// which reads through historicalStore instead.
+	stateStore store.CommitMultiStore
+
+	// historicalStore, when set, pins this Database to a single past IAVL
+	// version via its own CacheMultiStore rather than the live stateStore.
+	// See OpenHistoricalDatabase.
+	historicalStore store.CacheMultiStore
+	// lookupDb maps [trie root hash] -> <version id>, so OpenTrie and
+	// OpenStorageTrie can resolve a root back to an IAVL version.
+	lookupDb dbm.DB
+	// codeDb maps [codeHash] -> <code>.
+	codeDb dbm.DB
+	// addrPreimageDb maps keccak256(key) -> <key> for every key written
+	// through Trie.TryUpdate: account addresses and raw storage slot keys.
+	addrPreimageDb dbm.DB
+	// trieDb backs TrieDB(): a raw key-value space, separate from the
+	// IAVL sub-stores, that go-ethereum's trie.Database/snapshot code can
+	// read and write directly through the ethdb.Database adapter.
+	trieDb dbm.DB
+	cdc    *amino.Codec
+
+	// Tracing, when true, is consulted by callers that want to enable
+	// extra diagnostics while executing against this Database.
+	Tracing bool
+
+	// CommitEvery controls how often Commit actually flushes the write-
+	// through caches below into stateStore: every CommitEvery calls to
+	// Commit, instead of every single one. Zero (the default) means
+	// "every block", matching the pre-cache behaviour. A higher value
+	// trades resumability granularity (a crash loses up to CommitEvery-1
+	// blocks of cached-but-unflushed state) for throughput, since most
+	// IAVL work is skipped on the blocks in between.
+	CommitEvery uint64
+
+	// accountsCache and storageCache absorb TryUpdate/TryDelete calls
+	// between flushes instead of hitting the underlying IAVL sub-stores
+	// on every block.
+	accountsCache    store.CacheKVStore
+	storageCache     store.CacheKVStore
+	blocksSinceFlush uint64
+
+	// pendingRoots maps a trie identifier (""  for the account trie,
+	// string(addrHash) for a storage trie) to the most recent root handed
+	// out by that trie's Commit since the last real flush. OpenTrie/
+	// OpenStorageTrie compare against this to serve already-open state
+	// straight from the cache.
+	pendingRoots map[string]ethcommon.Hash
+
+	// pendingHeights queues RecordHeight calls made since the last real
+	// flush. stateStore.LastCommitID().Version doesn't advance until a
+	// flush actually happens, so recording these immediately would index
+	// every height in an unflushed batch against the same stale version;
+	// instead they wait here and get backfilled with the real version once
+	// flush() runs. Until then, VersionForHeight/RootForHeight correctly
+	// report "not yet committed" for these heights rather than silently
+	// resolving to the wrong state.
+	pendingHeights []pendingHeight
+
+	cacheMetrics CacheMetrics
+}
+
+// pendingHeight is one RecordHeight call queued in pendingHeights, awaiting
+// the IAVL version the next real flush produces.
+type pendingHeight struct {
+	height uint64
+	root   ethcommon.Hash
+}
+
+// CacheMetrics counts how often OpenTrie/OpenStorageTrie were satisfied
+// by the in-memory write-through cache (Hits) versus required resolving
+// and loading an IAVL version (Misses).
+type CacheMetrics struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// CacheMetrics returns a snapshot of the current hit/miss counters.
+func (d *Database) CacheMetrics() CacheMetrics {
+	return CacheMetrics{
+		Hits:   atomic.LoadUint64(&d.cacheMetrics.Hits),
+		Misses: atomic.LoadUint64(&d.cacheMetrics.Misses),
+	}
+}
+
+// NewDatabase constructs a Database backed by the given dbm.DB handles.
+func NewDatabase(stateDb, lookupDb, addrPreimageDb, codeDb, trieDb dbm.DB) (*Database, error) {
+	d := &Database{}
+	d.stateStore = store.NewCommitMultiStore(stateDb)
+	d.stateStore.MountStoreWithDB(AccountsKey, sdk.StoreTypeIAVL, nil)
+	d.stateStore.MountStoreWithDB(StorageKey, sdk.StoreTypeIAVL, nil)
+	if err := d.stateStore.LoadLatestVersion(); err != nil {
+		return nil, err
+	}
+	d.lookupDb = lookupDb
+	d.addrPreimageDb = addrPreimageDb
+	d.codeDb = codeDb
+	d.trieDb = trieDb
+	d.cdc = amino.NewCodec()
+	d.resetCache()
+	return d, nil
+}
+
+// resetCache (re)builds the write-through caches on top of whatever
+// version stateStore currently has loaded, and drops any pending roots:
+// they were computed against state that's no longer current.
+func (d *Database) resetCache() {
+	d.accountsCache = store.NewCacheKVStore(d.stateStore.GetCommitKVStore(AccountsKey))
+	d.storageCache = store.NewCacheKVStore(d.stateStore.GetCommitKVStore(StorageKey))
+	d.pendingRoots = make(map[string]ethcommon.Hash)
+	d.blocksSinceFlush = 0
+}
+
+// LatestVersion returns the most recently committed IAVL version, i.e. the
+// last imported block height.
+func (d *Database) LatestVersion() int64 {
+	return d.stateStore.LastCommitID().Version
+}
+
+// Commit flushes the write-through caches into stateStore and commits it,
+// unless CommitEvery says to defer: in that case the caches keep
+// absorbing writes and the previous CommitID is returned unchanged.
+func (d *Database) Commit() sdk.CommitID {
+	d.blocksSinceFlush++
+	if d.CommitEvery > 1 && d.blocksSinceFlush < d.CommitEvery {
+		return d.stateStore.LastCommitID()
+	}
+	return d.flush()
+}
+
+// Flush forces an immediate flush and commit of whatever the write-through
+// caches are currently holding, regardless of CommitEvery. Commit alone
+// only flushes once every CommitEvery calls, so a run that stops (cleanly
+// or via checkpoint) between flushes would otherwise leave up to
+// CommitEvery-1 blocks' worth of account/storage writes cached but never
+// persisted to stateStore, even though the caller believes them committed.
+// Callers that checkpoint or otherwise rely on everything committed so far
+// actually being durable (e.g. Importer.commit, before saving a Checkpoint)
+// must call this first.
+func (d *Database) Flush() sdk.CommitID {
+	if d.blocksSinceFlush == 0 {
+		return d.stateStore.LastCommitID()
+	}
+	return d.flush()
+}
+
+func (d *Database) flush() sdk.CommitID {
+	d.accountsCache.Write()
+	d.storageCache.Write()
+	commitID := d.stateStore.Commit()
+
+	for _, root := range d.pendingRoots {
+		if err := d.recordRoot(root, commitID.Version); err != nil {
+			// The importer treats this as non-fatal: worst case, a
+			// future OpenTrie/OpenStorageTrie for this root has to fall
+			// back to resolving it some other way instead of a direct
+			// lookup.
+			continue
+		}
+	}
+	for _, ph := range d.pendingHeights {
+		if err := d.recordHeight(ph.height, ph.root, commitID.Version); err != nil {
+			continue
+		}
+	}
+
+	d.pendingRoots = make(map[string]ethcommon.Hash)
+	d.pendingHeights = nil
+	d.blocksSinceFlush = 0
+	return commitID
+}
+
+func (d *Database) recordRoot(root ethcommon.Hash, version int64) error {
+	b, err := d.cdc.MarshalBinary(lookupValue{VersionID: version})
+	if err != nil {
+		return err
+	}
+	d.lookupDb.Set(root[:], b)
+	return nil
+}
+
+// softRoot computes a per-block, per-trie placeholder root for the trie
+// identified by prefix while its writes are only absorbed by the cache
+// and haven't actually been committed to IAVL yet. It is NOT a Merkle
+// hash of the trie's contents -- with CommitEvery > 1 there's no real
+// tree to hash until the next flush -- just a value that's unique to this
+// trie and this point in the cached batch, stable enough for OpenTrie to
+// recognise "this is the state I already have open" and for Commit to
+// later map it to the real IAVL version once flushed.
+func (d *Database) softRoot(prefix []byte) ethcommon.Hash {
+	last := d.stateStore.LastCommitID()
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, d.blocksSinceFlush)
+	return ethcommon.BytesToHash(ethcrypto.Keccak256(last.Hash, prefix, buf))
+}
+
+// Preimage returns the raw key previously recorded under keccak256(key)
+// by Trie.TryUpdate, or an error if hash isn't known.
+func (d *Database) Preimage(hash ethcommon.Hash) ([]byte, error) {
+	val := d.addrPreimageDb.Get(hash[:])
+	if val == nil {
+		return nil, fmt.Errorf("no preimage recorded for hash %x", hash)
+	}
+	return val, nil
+}
+
+// heightKey namespaces block-height index entries within lookupDb so they
+// can't collide with the 32-byte root-hash keys recordRoot uses.
+func heightKey(height uint64) []byte {
+	key := make([]byte, 2+8)
+	copy(key, "h:")
+	binary.BigEndian.PutUint64(key[2:], height)
+	return key
+}
+
+// RecordHeight indexes height against the IAVL version its state ends up
+// at once flushed, and root, the account trie root committed for that
+// height, so a later VersionForHeight/RootForHeight can resolve either
+// back. Under CommitEvery batching, stateStore.LastCommitID().Version is
+// still the version from the *previous* flush -- this height's writes
+// haven't reached stateStore yet -- so recording it immediately would
+// point VersionForHeight at the wrong (stale) state. Instead the entry is
+// queued in pendingHeights and only written once flush() knows the real
+// version; until then, VersionForHeight/RootForHeight report this height
+// as not yet committed, same as any other height that hasn't happened.
+func (d *Database) RecordHeight(height uint64, root ethcommon.Hash) error {
+	if d.CommitEvery > 1 {
+		d.pendingHeights = append(d.pendingHeights, pendingHeight{height: height, root: root})
+		return nil
+	}
+	return d.recordHeight(height, root, d.stateStore.LastCommitID().Version)
+}
+
+func (d *Database) recordHeight(height uint64, root ethcommon.Hash, version int64) error {
+	b, err := d.cdc.MarshalBinary(lookupValue{VersionID: version, Root: root})
+	if err != nil {
+		return err
+	}
+	d.lookupDb.Set(heightKey(height), b)
+	return nil
+}
+
+// heightLookup reads back the lookupValue RecordHeight stored for height.
+func (d *Database) heightLookup(height uint64) (lookupValue, error) {
+	var lv lookupValue
+	val := d.lookupDb.Get(heightKey(height))
+	if val == nil {
+		return lv, fmt.Errorf("no committed state recorded for block %d", height)
+	}
+	_, err := d.cdc.UnmarshalBinaryReader(bytes.NewBuffer(val), &lv, 0)
+	return lv, err
+}
+
+// VersionForHeight resolves a block height indexed by RecordHeight back
+// to an IAVL version.
+func (d *Database) VersionForHeight(height uint64) (int64, error) {
+	lv, err := d.heightLookup(height)
+	if err != nil {
+		return 0, err
+	}
+	return lv.VersionID, nil
+}
+
+// RootForHeight resolves a block height indexed by RecordHeight back to
+// the account trie root it committed, for reopening via OpenTrie when
+// replaying a historical block (e.g. for debug_traceTransaction).
+func (d *Database) RootForHeight(height uint64) (ethcommon.Hash, error) {
+	lv, err := d.heightLookup(height)
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	return lv.Root, nil
+}
+
+// OpenHistoricalDatabase returns a Database pinned to an exact past IAVL
+// version, for read-only historical queries (debug_traceTransaction,
+// QueryStorageAt) that must not disturb a live, concurrently-importing
+// Database. d.stateStore.LoadVersion swaps the version the *entire* live
+// multistore has loaded and d.resetCache discards accountsCache/
+// storageCache's unflushed writes -- calling either from a query running
+// alongside an in-progress import corrupts it. The Database this returns
+// instead holds its own CacheMultiStore opened at version via
+// CacheMultiStoreWithVersion: reads against it never touch d.stateStore,
+// d.accountsCache or d.storageCache, and since a CacheMultiStore only
+// ever caches writes in memory, nothing it does can reach the live
+// store's backing IAVL trees either.
+func (d *Database) OpenHistoricalDatabase(version int64) (*Database, error) {
+	cms, err := d.stateStore.CacheMultiStoreWithVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	return &Database{
+		historicalStore: cms,
+		lookupDb:        d.lookupDb,
+		addrPreimageDb:  d.addrPreimageDb,
+		codeDb:          d.codeDb,
+		trieDb:          d.trieDb,
+		cdc:             d.cdc,
+	}, nil
+}
+
+// OpenStorageTrieAtVersion opens addrHash's storage trie as of an exact
+// IAVL version rather than a root hash, for read-only historical queries
+// (e.g. QueryStorageAt) that already resolved a block height to a
+// version via VersionForHeight.
+func (d *Database) OpenStorageTrieAtVersion(addrHash ethcommon.Hash, version int64) (ethstate.Trie, error) {
+	hd, err := d.OpenHistoricalDatabase(version)
+	if err != nil {
+		return nil, err
+	}
+	return hd.OpenStorageTrie(addrHash, ethcommon.Hash{})
+}
+
+func (d *Database) versionForRoot(root ethcommon.Hash) (int64, error) {
+	val := d.lookupDb.Get(root[:])
+	if val == nil {
+		return 0, fmt.Errorf("could not find version with root hash %x", root[:])
+	}
+	var lv lookupValue
+	if _, err := d.cdc.UnmarshalBinaryReader(bytes.NewBuffer(val), &lv, 0); err != nil {
+		return 0, err
+	}
+	return lv.VersionID, nil
+}
+
+// OpenTrie implements state.Database. A root matching the account trie's
+// last reported (possibly still-cached) root is served straight from
+// accountsCache; anything else resolves to an IAVL version and
+// invalidates the caches, since they were built against different state.
+// On a Database returned by OpenHistoricalDatabase, root is ignored
+// entirely: historicalStore already pins a single version, so there's
+// nothing to resolve or cache-invalidate.
+func (d *Database) OpenTrie(root ethcommon.Hash) (ethstate.Trie, error) {
+	if d.historicalStore != nil {
+		return &Trie{db: d, store: d.historicalStore.GetKVStore(AccountsKey), prefix: nil}, nil
+	}
+
+	if root != (ethcommon.Hash{}) && root == d.pendingRoots[""] {
+		atomic.AddUint64(&d.cacheMetrics.Hits, 1)
+		return &Trie{db: d, store: d.accountsCache, prefix: nil}, nil
+	}
+
+	if root != (ethcommon.Hash{}) {
+		atomic.AddUint64(&d.cacheMetrics.Misses, 1)
+		version, err := d.versionForRoot(root)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.stateStore.LoadVersion(version); err != nil {
+			return nil, err
+		}
+		d.resetCache()
+	}
+	return &Trie{db: d, store: d.accountsCache, prefix: nil}, nil
+}
+
+// OpenStorageTrie implements state.Database, with the same cache-hit rule
+// as OpenTrie keyed by addrHash instead of the account trie's fixed "".
+// Same historicalStore short-circuit as OpenTrie.
+func (d *Database) OpenStorageTrie(addrHash, root ethcommon.Hash) (ethstate.Trie, error) {
+	if d.historicalStore != nil {
+		return &Trie{db: d, store: d.historicalStore.GetKVStore(StorageKey), prefix: addrHash[:]}, nil
+	}
+
+	key := string(addrHash[:])
+	if root != (ethcommon.Hash{}) && root == d.pendingRoots[key] {
+		atomic.AddUint64(&d.cacheMetrics.Hits, 1)
+		return &Trie{db: d, store: d.storageCache, prefix: addrHash[:]}, nil
+	}
+
+	if root != (ethcommon.Hash{}) {
+		atomic.AddUint64(&d.cacheMetrics.Misses, 1)
+		version, err := d.versionForRoot(root)
+		if err != nil {
+			return nil, err
+		}
+		// Loading the version here isn't strictly required -- we only
+		// need accounts and storage to stay consistent with each other.
+		if err := d.stateStore.LoadVersion(version); err != nil {
+			return nil, err
+		}
+		d.resetCache()
+	}
+	return &Trie{db: d, store: d.storageCache, prefix: addrHash[:]}, nil
+}
+
+// CopyTrie implements state.Database. It returns an in-memory snapshot of
+// t backed by a store.CacheKVStore, so callers (e.g. StateDB.Copy, used
+// heavily for speculative execution) can mutate the copy without those
+// writes reaching the underlying IAVL sub-store until/unless the copy's
+// Commit is itself called.
+func (d *Database) CopyTrie(t ethstate.Trie) ethstate.Trie {
+	ot, ok := t.(*Trie)
+	if !ok {
+		return nil
+	}
+	return &Trie{db: d, store: store.NewCacheKVStore(ot.store), prefix: ot.prefix}
+}
+
+// ContractCode implements state.Database.
+func (d *Database) ContractCode(addrHash, codeHash ethcommon.Hash) ([]byte, error) {
+	return d.codeDb.Get(codeHash[:]), nil
+}
+
+// ContractCodeSize implements state.Database.
+func (d *Database) ContractCodeSize(addrHash, codeHash ethcommon.Hash) (int, error) {
+	return len(d.codeDb.Get(codeHash[:])), nil
+}
+
+// TrieDB implements state.Database. It's backed by d.trieDb through the
+// state/ethdb adapter, giving go-ethereum's trie.Database (and anything
+// built on it, e.g. snapshot generation or eth_getProof) a real key-value
+// space to work with instead of the nil stub this used to return.
+func (d *Database) TrieDB() *ethtrie.Database {
+	return ethtrie.NewDatabase(ethdb.New(d.trieDb))
+}
+
+// kvStore is the subset of store.KVStore that Trie needs. It's satisfied
+// both by store.CommitKVStore (a real, versioned IAVL sub-store) and by
+// store.CacheKVStore (the in-memory snapshot CopyTrie hands out).
+type kvStore interface {
+	Get(key []byte) []byte
+	Set(key, value []byte)
+	Delete(key []byte)
+	Iterator(start, end []byte) sdk.Iterator
+}
+
+// Trie implements the go-ethereum state.Trie interface over a single
+// prefix of one of Database's sub-stores. It was previously named
+// OurTrie and lived in package main.
+type Trie struct {
+	db     *Database
+	store  kvStore
+	prefix []byte
+}
+
+func (t *Trie) prefixed(key []byte) []byte {
+	kk := make([]byte, len(t.prefix)+len(key))
+	copy(kk, t.prefix)
+	copy(kk[len(t.prefix):], key)
+	return kk
+}
+
+// TryGet implements state.Trie.
+func (t *Trie) TryGet(key []byte) ([]byte, error) {
+	if t.prefix == nil {
+		return t.store.Get(key), nil
+	}
+	return t.store.Get(t.prefixed(key)), nil
+}
+
+// TryUpdate implements state.Trie. For both the account trie (key is an
+// address) and a storage trie (key is a raw slot key), it also records
+// keccak256(key) -> key in addrPreimageDb, the same SHA3-preimage trick
+// go-ethereum's own SecureTrie uses: our state isn't hashed at rest, so
+// the raw key is already the preimage of the hash callers like GetKey are
+// asking to reverse.
+func (t *Trie) TryUpdate(key, value []byte) error {
+	t.recordPreimage(key)
+	if t.prefix == nil {
+		t.store.Set(key, value)
+		return nil
+	}
+	t.store.Set(t.prefixed(key), value)
+	return nil
+}
+
+func (t *Trie) recordPreimage(key []byte) {
+	hash := ethcrypto.Keccak256(key)
+	preimage := make([]byte, len(key))
+	copy(preimage, key)
+	t.db.addrPreimageDb.Set(hash, preimage)
+}
+
+// TryDelete implements state.Trie.
+func (t *Trie) TryDelete(key []byte) error {
+	if t.prefix == nil {
+		t.store.Delete(key)
+		return nil
+	}
+	t.store.Delete(t.prefixed(key))
+	return nil
+}
+
+// Commit implements state.Trie.
+//
+//   - If t is backed by one of Database's live write-through caches
+//     (accountsCache/storageCache), the writes stay cached; Commit just
+//     hands back a placeholder root (see Database.softRoot) and records it
+//     as pending, so Database.Commit can map it to a real IAVL version
+//     once/if it actually flushes.
+//   - If t is backed by some other CacheKVStore, it's a CopyTrie snapshot:
+//     there's nothing to version, so Commit just flushes the buffered
+//     writes through to the store it was copied from and returns the zero
+//     hash, matching Hash() below.
+//   - Otherwise t.store is a real CommitKVStore (caching disabled), and
+//     Commit advances the IAVL version as before.
+func (t *Trie) Commit(onleaf ethtrie.LeafCallback) (ethcommon.Hash, error) {
+	key := string(t.prefix)
+	if t.store == t.db.accountsCache || t.store == t.db.storageCache {
+		root := t.db.softRoot(t.prefix)
+		t.db.pendingRoots[key] = root
+		return root, nil
+	}
+
+	if cache, ok := t.store.(store.CacheKVStore); ok {
+		cache.Write()
+		return ethcommon.Hash{}, nil
+	}
+
+	commitStore, ok := t.store.(store.CommitKVStore)
+	if !ok {
+		return ethcommon.Hash{}, fmt.Errorf("trie store of type %T does not support Commit", t.store)
+	}
+	commitID := commitStore.Commit()
+
+	var hash ethcommon.Hash
+	copy(hash[:], commitID.Hash)
+
+	b, err := t.db.cdc.MarshalBinary(lookupValue{VersionID: commitID.Version})
+	if err != nil {
+		return hash, err
+	}
+	t.db.lookupDb.Set(hash[:], b)
+	return hash, nil
+}
+
+// Hash implements state.Trie. A trie backed by one of Database's live
+// write-through caches reports the same placeholder root its Commit would
+// return at this point (see Database.softRoot), so StateDB.IntermediateRoot
+// yields a root without committing; any other trie reports the zero hash.
+func (t *Trie) Hash() ethcommon.Hash {
+	if t.store == t.db.accountsCache || t.store == t.db.storageCache {
+		return t.db.softRoot(t.prefix)
+	}
+	return ethcommon.Hash{}
+}
+
+// NodeIterator implements state.Trie by walking the IAVL sub-store
+// directly rather than descending through real trie nodes: since
+// Database stores account/storage entries as flat key-value pairs rather
+// than an actual Merkle Patricia Trie, every entry this store holds is a
+// leaf. See node_iterator.go.
+func (t *Trie) NodeIterator(startKey []byte) ethtrie.NodeIterator {
+	return newNodeIterator(t, startKey)
+}
+
+// GetKey implements state.Trie by reversing a SHA3 preimage recorded by
+// TryUpdate back into the raw key it was computed from.
+func (t *Trie) GetKey(hash []byte) []byte {
+	preimage, err := t.db.Preimage(ethcommon.BytesToHash(hash))
+	if err != nil {
+		return nil
+	}
+	return preimage
+}
+
+// proofBytes asks the underlying CommitMultiStore for an IAVL range proof
+// of key (the same mechanism ABCI query proofs use), amino-marshaled.
+// Because entries here are leaves of a flat IAVL sub-store rather than of
+// an MPT, there's no chain of branch/extension node hashes to hand back
+// the way go-ethereum's own trie would -- callers get this one opaque
+// blob instead. Shared by Prove (which stores it for an eth_getProof
+// response) and nodeIterator.LeafProof (which returns it directly).
+//
+// A Trie opened against a Database returned by OpenHistoricalDatabase has
+// no stateStore of its own (it reads through historicalStore, a plain
+// store.CacheMultiStore with no ABCI query path to ask for a proof), so
+// that case is rejected with an error instead of panicking on the nil
+// type assertion below.
+func (t *Trie) proofBytes(key []byte) ([]byte, error) {
+	if t.db.stateStore == nil {
+		return nil, fmt.Errorf("proof query not supported against a historical Database snapshot")
+	}
+
+	storeName := accountsStoreName
+	if t.prefix != nil {
+		storeName = storageStoreName
+	}
+
+	req := abci.RequestQuery{
+		Path:  fmt.Sprintf("/%s/key", storeName),
+		Data:  t.prefixed(key),
+		Prove: true,
+	}
+	res := t.db.stateStore.(sdk.Queryable).Query(req)
+	if res.Code != 0 {
+		return nil, fmt.Errorf("proof query for key %x failed: %s", key, res.Log)
+	}
+
+	return t.db.cdc.MarshalBinary(res.Proof)
+}
+
+// Prove implements state.Trie by storing proofBytes(key) in proofDb keyed
+// by the hash of the storage key, which is what callers assembling an
+// eth_getProof response read back.
+func (t *Trie) Prove(key []byte, fromLevel uint, proofDb ethethdb.Putter) error {
+	proofBytes, err := t.proofBytes(key)
+	if err != nil {
+		return err
+	}
+	return proofDb.Put(ethcommon.BytesToHash(key).Bytes(), proofBytes)
+}
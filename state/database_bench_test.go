@@ -0,0 +1,65 @@
+package state_test
+
+import (
+	"math/big"
+	"testing"
+
+	dbm "github.com/tendermint/tmlibs/db"
+
+	"github.com/cosmos/ethermint/state"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethstate "github.com/ethereum/go-ethereum/core/state"
+)
+
+// blocksPerRun is large enough that CommitEvery's batching has room to
+// show a difference, without making the default benchmark run too slow.
+const blocksPerRun = 200
+
+// benchmarkCommitEvery replays blocksPerRun blocks, each writing one
+// account balance and calling Database.Commit the same way Importer.commit
+// does, gating the write-through cache added by Database.CommitEvery
+// against an actual throughput number instead of just trusting it helps.
+func benchmarkCommitEvery(b *testing.B, commitEvery uint64) {
+	for i := 0; i < b.N; i++ {
+		db, err := state.NewDatabase(
+			dbm.NewDB("state", dbm.MemDBBackend, ""),
+			dbm.NewDB("lookup", dbm.MemDBBackend, ""),
+			dbm.NewDB("addrPreimage", dbm.MemDBBackend, ""),
+			dbm.NewDB("code", dbm.MemDBBackend, ""),
+			dbm.NewDB("trie", dbm.MemDBBackend, ""),
+		)
+		if err != nil {
+			b.Fatalf("NewDatabase: %v", err)
+		}
+		db.CommitEvery = commitEvery
+
+		root := ethcommon.Hash{}
+		for block := 0; block < blocksPerRun; block++ {
+			statedb, err := ethstate.New(root, db)
+			if err != nil {
+				b.Fatalf("ethstate.New: %v", err)
+			}
+			statedb.AddBalance(ethcommon.BigToAddress(big.NewInt(int64(block))), big.NewInt(1))
+
+			newRoot, err := statedb.Commit(false)
+			if err != nil {
+				b.Fatalf("Commit: %v", err)
+			}
+			db.Commit()
+			root = newRoot
+		}
+	}
+}
+
+// BenchmarkCommitPerBlock is the CommitEvery=0 baseline (flush every
+// block) the write-through cache is meant to improve on.
+func BenchmarkCommitPerBlock(b *testing.B) {
+	benchmarkCommitEvery(b, 0)
+}
+
+// BenchmarkCommitBatched flushes only every 20 blocks, the same trade
+// ImportConfig.CommitEvery exposes to callers.
+func BenchmarkCommitBatched(b *testing.B) {
+	benchmarkCommitEvery(b, 20)
+}
@@ -0,0 +1,118 @@
+package state_test
+
+import (
+	"math/big"
+	"testing"
+
+	dbm "github.com/tendermint/tmlibs/db"
+
+	"github.com/cosmos/ethermint/state"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethstate "github.com/ethereum/go-ethereum/core/state"
+)
+
+func newTestDatabase(t *testing.T) *state.Database {
+	t.Helper()
+
+	db, err := state.NewDatabase(
+		dbm.NewDB("state", dbm.MemDBBackend, ""),
+		dbm.NewDB("lookup", dbm.MemDBBackend, ""),
+		dbm.NewDB("addrPreimage", dbm.MemDBBackend, ""),
+		dbm.NewDB("code", dbm.MemDBBackend, ""),
+		dbm.NewDB("trie", dbm.MemDBBackend, ""),
+	)
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+	return db
+}
+
+// TestIntermediateRootAndIteration exercises the path a block importer
+// relies on: writing through go-ethereum's StateDB, IntermediateRoot
+// computing a root without committing, Commit flushing it, and the
+// resulting trie being iterable with each leaf provable.
+func TestIntermediateRootAndIteration(t *testing.T) {
+	db := newTestDatabase(t)
+
+	statedb, err := ethstate.New(ethcommon.Hash{}, db)
+	if err != nil {
+		t.Fatalf("ethstate.New: %v", err)
+	}
+
+	addrs := []ethcommon.Address{
+		ethcommon.HexToAddress("0x1111111111111111111111111111111111111111"),
+		ethcommon.HexToAddress("0x2222222222222222222222222222222222222222"),
+	}
+	for i, addr := range addrs {
+		statedb.AddBalance(addr, big.NewInt(int64(100*(i+1))))
+	}
+
+	if root := statedb.IntermediateRoot(false); root == (ethcommon.Hash{}) {
+		t.Fatalf("IntermediateRoot returned the zero hash after writes")
+	}
+
+	root, err := statedb.Commit(false)
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	db.Commit()
+
+	trie, err := db.OpenTrie(root)
+	if err != nil {
+		t.Fatalf("OpenTrie(%x): %v", root, err)
+	}
+
+	var leaves int
+	it := trie.NodeIterator(nil)
+	for it.Next(true) {
+		if !it.Leaf() {
+			continue
+		}
+		leaves++
+
+		proof := it.LeafProof()
+		if len(proof) == 0 {
+			t.Fatalf("LeafProof returned no proof for key %x", it.LeafKey())
+		}
+	}
+	if err := it.Error(); err != nil {
+		t.Fatalf("NodeIterator: %v", err)
+	}
+	if leaves != len(addrs) {
+		t.Errorf("NodeIterator visited %d leaves, want %d", leaves, len(addrs))
+	}
+}
+
+// TestHistoricalProofReturnsError exercises a Trie opened against
+// OpenHistoricalDatabase, which has no stateStore of its own -- proofBytes
+// must report that as an error rather than panic on the nil type assertion
+// it used to make unconditionally.
+func TestHistoricalProofReturnsError(t *testing.T) {
+	db := newTestDatabase(t)
+
+	statedb, err := ethstate.New(ethcommon.Hash{}, db)
+	if err != nil {
+		t.Fatalf("ethstate.New: %v", err)
+	}
+	addr := ethcommon.HexToAddress("0x3333333333333333333333333333333333333333")
+	statedb.AddBalance(addr, big.NewInt(100))
+	if _, err := statedb.Commit(false); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	commitID := db.Commit()
+
+	historical, err := db.OpenHistoricalDatabase(commitID.Version)
+	if err != nil {
+		t.Fatalf("OpenHistoricalDatabase: %v", err)
+	}
+
+	trie, err := historical.OpenTrie(ethcommon.Hash{})
+	if err != nil {
+		t.Fatalf("OpenTrie: %v", err)
+	}
+
+	if err := trie.Prove(addr.Bytes(), 0, nil); err == nil {
+		t.Fatalf("Prove against a historical Database returned no error")
+	}
+}
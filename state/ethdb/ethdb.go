@@ -0,0 +1,153 @@
+// Package ethdb adapts a tendermint dbm.DB key-value store to the
+// go-ethereum ethdb.Database interface, so go-ethereum components that
+// expect a raw key-value store -- trie.Database, snapshot generation,
+// state sync -- can run directly on top of Ethermint's storage instead of
+// needing their own leveldb instance.
+package ethdb
+
+import (
+	"errors"
+
+	dbm "github.com/tendermint/tmlibs/db"
+
+	ethethdb "github.com/ethereum/go-ethereum/ethdb"
+)
+
+// ErrNotFound is returned by Get for a key that isn't present, mirroring
+// leveldb.ErrNotFound which go-ethereum's trie package checks for.
+var ErrNotFound = errors.New("ethdb: not found")
+
+// Database implements ethdb.Database over a dbm.DB.
+type Database struct {
+	db dbm.DB
+}
+
+// New wraps db as a go-ethereum ethdb.Database.
+func New(db dbm.DB) *Database {
+	return &Database{db: db}
+}
+
+// Put implements ethdb.Putter.
+func (d *Database) Put(key, value []byte) error {
+	d.db.Set(key, value)
+	return nil
+}
+
+// Get implements ethdb.Database.
+func (d *Database) Get(key []byte) ([]byte, error) {
+	val := d.db.Get(key)
+	if val == nil {
+		return nil, ErrNotFound
+	}
+	return val, nil
+}
+
+// Has implements ethdb.Database.
+func (d *Database) Has(key []byte) (bool, error) {
+	return d.db.Has(key), nil
+}
+
+// Delete implements ethdb.Deleter.
+func (d *Database) Delete(key []byte) error {
+	d.db.Delete(key)
+	return nil
+}
+
+// Close implements ethdb.Database.
+func (d *Database) Close() {}
+
+// NewBatch implements ethdb.Database.
+func (d *Database) NewBatch() ethethdb.Batch {
+	return &batch{db: d.db}
+}
+
+// NewIterator implements ethdb.Database, returning an iterator over the
+// entire keyspace.
+func (d *Database) NewIterator() ethethdb.Iterator {
+	return newIterator(d.db.Iterator(nil, nil))
+}
+
+type op struct {
+	delete bool
+	key    []byte
+	value  []byte
+}
+
+// batch implements ethdb.Batch by buffering operations and replaying them
+// against db on Write, matching the write-batch semantics go-ethereum's
+// trie and snapshot code relies on.
+type batch struct {
+	db   dbm.DB
+	ops  []op
+	size int
+}
+
+func (b *batch) Put(key, value []byte) error {
+	b.ops = append(b.ops, op{key: key, value: value})
+	b.size += len(key) + len(value)
+	return nil
+}
+
+func (b *batch) Delete(key []byte) error {
+	b.ops = append(b.ops, op{delete: true, key: key})
+	b.size += len(key)
+	return nil
+}
+
+func (b *batch) ValueSize() int {
+	return b.size
+}
+
+func (b *batch) Write() error {
+	for _, o := range b.ops {
+		if o.delete {
+			b.db.Delete(o.key)
+		} else {
+			b.db.Set(o.key, o.value)
+		}
+	}
+	return nil
+}
+
+func (b *batch) Reset() {
+	b.ops = b.ops[:0]
+	b.size = 0
+}
+
+// iterator adapts a dbm.Iterator to ethdb.Iterator. dbm.Iterator already
+// starts positioned on the first entry, whereas ethdb.Iterator expects
+// Next to be called before the first Key/Value access, so it tracks
+// whether it has been advanced yet.
+type iterator struct {
+	it      dbm.Iterator
+	started bool
+}
+
+func newIterator(it dbm.Iterator) *iterator {
+	return &iterator{it: it}
+}
+
+func (i *iterator) Next() bool {
+	if !i.started {
+		i.started = true
+	} else {
+		i.it.Next()
+	}
+	return i.it.Valid()
+}
+
+func (i *iterator) Error() error {
+	return nil
+}
+
+func (i *iterator) Key() []byte {
+	return i.it.Key()
+}
+
+func (i *iterator) Value() []byte {
+	return i.it.Value()
+}
+
+func (i *iterator) Release() {
+	i.it.Close()
+}
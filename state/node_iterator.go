@@ -0,0 +1,143 @@
+package state
+
+import (
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethtrie "github.com/ethereum/go-ethereum/trie"
+)
+
+// nodeIterator implements go-ethereum's trie.NodeIterator over a Trie.
+// Database doesn't keep a real Merkle Patricia Trie internally -- it's a
+// flat IAVL-backed key-value store -- so there are no intermediate
+// branch/extension nodes to visit; every entry is a leaf at depth zero.
+// This is enough for callers that only care about enumerating accounts or
+// storage slots (state snapshot/iteration, eth_getProof's key-existence
+// walk); it is not a faithful MPT traversal.
+type nodeIterator struct {
+	trie    *Trie
+	it      ethIterator
+	started bool
+	err     error
+}
+
+// ethIterator is the subset of sdk.Iterator this file needs; it exists so
+// tests can stub it without pulling in a real store.
+type ethIterator interface {
+	Valid() bool
+	Next()
+	Key() []byte
+	Value() []byte
+	Close()
+}
+
+func newNodeIterator(t *Trie, startKey []byte) *nodeIterator {
+	var start []byte
+	if t.prefix != nil {
+		start = t.prefixed(startKey)
+	} else {
+		start = startKey
+	}
+
+	var end []byte
+	if t.prefix != nil {
+		end = prefixEnd(t.prefix)
+	}
+
+	return &nodeIterator{
+		trie: t,
+		it:   t.store.Iterator(start, end),
+	}
+}
+
+// Next implements trie.NodeIterator.
+func (ni *nodeIterator) Next(descend bool) bool {
+	if ni.err != nil {
+		return false
+	}
+	if !ni.started {
+		ni.started = true
+		return ni.it.Valid()
+	}
+	if !ni.it.Valid() {
+		return false
+	}
+	ni.it.Next()
+	return ni.it.Valid()
+}
+
+// Error implements trie.NodeIterator.
+func (ni *nodeIterator) Error() error {
+	return ni.err
+}
+
+// Hash implements trie.NodeIterator. There's no node hash to report for a
+// flat leaf, so it returns the zero hash.
+func (ni *nodeIterator) Hash() ethcommon.Hash {
+	return ethcommon.Hash{}
+}
+
+// Parent implements trie.NodeIterator; every entry is a root-level leaf.
+func (ni *nodeIterator) Parent() ethcommon.Hash {
+	return ethcommon.Hash{}
+}
+
+// Path implements trie.NodeIterator.
+func (ni *nodeIterator) Path() []byte {
+	return ni.unprefixedKey()
+}
+
+// Leaf implements trie.NodeIterator; every entry visited is a leaf.
+func (ni *nodeIterator) Leaf() bool {
+	return ni.it.Valid()
+}
+
+// LeafKey implements trie.NodeIterator.
+func (ni *nodeIterator) LeafKey() []byte {
+	return ni.unprefixedKey()
+}
+
+// LeafBlob implements trie.NodeIterator.
+func (ni *nodeIterator) LeafBlob() []byte {
+	return ni.it.Value()
+}
+
+// LeafProof implements trie.NodeIterator by delegating to the same IAVL
+// range-proof query Trie.Prove uses. Trie.Prove's signature is shaped for
+// eth_getProof (it writes into a caller-supplied ethdb.Putter and returns
+// only an error), so this calls the shared proofBytes helper directly
+// instead and wraps the single opaque proof blob it gets back in a
+// one-element slice.
+func (ni *nodeIterator) LeafProof() [][]byte {
+	proof, err := ni.trie.proofBytes(ni.unprefixedKey())
+	if err != nil {
+		ni.err = err
+		return nil
+	}
+	return [][]byte{proof}
+}
+
+// AddResolver implements trie.NodeIterator; there's no remote node
+// resolution to do over a local key-value store.
+func (ni *nodeIterator) AddResolver(ethtrie.NodeResolver) {}
+
+func (ni *nodeIterator) unprefixedKey() []byte {
+	key := ni.it.Key()
+	if ni.trie.prefix == nil {
+		return key
+	}
+	return key[len(ni.trie.prefix):]
+}
+
+// prefixEnd returns the smallest key that sorts after every key with the
+// given prefix, for use as an exclusive iterator upper bound.
+func prefixEnd(prefix []byte) []byte {
+	end := make([]byte, len(prefix))
+	copy(end, prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+	// prefix was all 0xff bytes; no upper bound needed.
+	return nil
+}
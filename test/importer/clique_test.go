@@ -0,0 +1,229 @@
+package importer_test
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cosmos/ethermint/state"
+	stateethdb "github.com/cosmos/ethermint/state/ethdb"
+	"github.com/cosmos/ethermint/test/importer"
+	"github.com/cosmos/ethermint/x/evm"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethclique "github.com/ethereum/go-ethereum/consensus/clique"
+	ethcore "github.com/ethereum/go-ethereum/core"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	ethrlp "github.com/ethereum/go-ethereum/rlp"
+
+	dbm "github.com/tendermint/tmlibs/db"
+)
+
+// cliqueTestKey is a fixed (not secret -- this is a public test vector),
+// deterministic private key so the fixture's signer address and every
+// header's seal are reproducible across test runs.
+const cliqueTestKey = "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f25"
+
+// loadCliqueTestnetGenesis reads data/clique_testnet.json -- the chain
+// config, difficulty and gas limit for the tiny single-signer Clique
+// testnet this test imports against -- and fills in the parts that
+// depend on the test's fixed signer key: the extraData signer list and
+// its funded genesis balance.
+func loadCliqueTestnetGenesis(t *testing.T, signer ethcommon.Address) *ethcore.Genesis {
+	t.Helper()
+
+	raw, err := ioutil.ReadFile(filepath.Join("..", "..", "data", "clique_testnet.json"))
+	if err != nil {
+		t.Fatalf("reading clique_testnet.json: %v", err)
+	}
+
+	var genesis ethcore.Genesis
+	if err := json.Unmarshal(raw, &genesis); err != nil {
+		t.Fatalf("unmarshaling clique_testnet.json: %v", err)
+	}
+
+	// EIP-225 extraData layout: 32 bytes vanity, one 20-byte signer
+	// address per authorized signer (only meaningful here since block 0
+	// is always a checkpoint), 65 bytes for the (unused at genesis) seal.
+	extra := make([]byte, 32+ethcommon.AddressLength+65)
+	copy(extra[32:], signer.Bytes())
+	genesis.ExtraData = extra
+
+	genesis.Alloc = ethcore.GenesisAlloc{
+		signer: {Balance: new(big.Int).Mul(big.NewInt(1e18), big.NewInt(100))},
+	}
+	return &genesis
+}
+
+// cliqueSigHash reproduces consensus/clique's unexported sigHash: the
+// RLP encoding of every header field except the seal itself, hashed with
+// Keccak256. It's what the signer actually signs, and what a real Clique
+// engine would recompute to verify the seal -- this importer never does
+// (Run calls Engine().Finalize, plus Engine().Author via ApplyTransaction
+// for blocks with transactions; neither checks the seal), but signing a fixture with a placeholder instead felt like cheating.
+func cliqueSigHash(header *ethtypes.Header) (ethcommon.Hash, error) {
+	b, err := ethrlp.EncodeToBytes([]interface{}{
+		header.ParentHash,
+		header.UncleHash,
+		header.Coinbase,
+		header.Root,
+		header.TxHash,
+		header.ReceiptHash,
+		header.Bloom,
+		header.Difficulty,
+		header.Number,
+		header.GasLimit,
+		header.GasUsed,
+		header.Time,
+		header.Extra[:len(header.Extra)-65],
+		header.MixDigest,
+		header.Nonce,
+	})
+	if err != nil {
+		return ethcommon.Hash{}, err
+	}
+	return ethcommon.BytesToHash(ethcrypto.Keccak256(b)), nil
+}
+
+// buildCliqueChain builds a count-block chain descending from genesis,
+// each header sealed by key, with no transactions -- enough to exercise
+// Importer.Run's genesis seeding, per-block commit and Clique Finalize
+// call without needing a real signed transaction set.
+func buildCliqueChain(t *testing.T, genesis *ethcore.Genesis, key *ecdsa.PrivateKey, count int) []*ethtypes.Block {
+	t.Helper()
+
+	blocks := make([]*ethtypes.Block, count)
+	parentHash := ethcommon.Hash{}
+	for i := 0; i < count; i++ {
+		extra := make([]byte, len(genesis.ExtraData))
+		copy(extra, genesis.ExtraData)
+
+		header := &ethtypes.Header{
+			ParentHash:  parentHash,
+			UncleHash:   ethtypes.EmptyUncleHash,
+			Coinbase:    ethcommon.Address{},
+			Root:        ethcommon.Hash{},
+			TxHash:      ethtypes.EmptyRootHash,
+			ReceiptHash: ethtypes.EmptyRootHash,
+			Difficulty:  big.NewInt(2),
+			Number:      big.NewInt(int64(i + 1)),
+			GasLimit:    genesis.GasLimit,
+			GasUsed:     0,
+			Time:        big.NewInt(int64(i + 1)),
+			Extra:       extra,
+			MixDigest:   ethcommon.Hash{},
+			Nonce:       ethtypes.BlockNonce{},
+		}
+
+		hash, err := cliqueSigHash(header)
+		if err != nil {
+			t.Fatalf("cliqueSigHash: %v", err)
+		}
+		sig, err := ethcrypto.Sign(hash.Bytes(), key)
+		if err != nil {
+			t.Fatalf("signing header %d: %v", i+1, err)
+		}
+		copy(header.Extra[len(header.Extra)-65:], sig)
+
+		block := ethtypes.NewBlockWithHeader(header)
+		blocks[i] = block
+		parentHash = block.Hash()
+	}
+	return blocks
+}
+
+// writeRLPChain RLP-encodes each block in sequence to path, the same
+// framing Run's ethrlp.NewStream decoder expects from a real block
+// export.
+func writeRLPChain(t *testing.T, path string, blocks []*ethtypes.Block) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("creating %s: %v", path, err)
+	}
+	defer f.Close()
+
+	for _, block := range blocks {
+		if err := ethrlp.Encode(f, block); err != nil {
+			t.Fatalf("encoding block %d: %v", block.NumberU64(), err)
+		}
+	}
+}
+
+// TestImportCliqueTestnet runs a pipelined import (importer.Importer.Run)
+// over a tiny, freshly-signed Clique chain built from data/
+// clique_testnet.json, verifying that a Clique engine (built with
+// ethclique.New from the fixture's config) -- not just the Importer's
+// Ethash default -- actually drives a successful import end to end.
+func TestImportCliqueTestnet(t *testing.T) {
+	signerKey, err := ethcrypto.HexToECDSA(cliqueTestKey)
+	if err != nil {
+		t.Fatalf("HexToECDSA: %v", err)
+	}
+	signer := ethcrypto.PubkeyToAddress(signerKey.PublicKey)
+
+	genesis := loadCliqueTestnetGenesis(t, signer)
+
+	const numBlocks = 3
+	blocks := buildCliqueChain(t, genesis, signerKey, numBlocks)
+
+	dir, err := ioutil.TempDir("", "clique-import-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	chainFile := filepath.Join(dir, "chain.rlp")
+	writeRLPChain(t, chainFile, blocks)
+
+	ethermintDB, err := state.NewDatabase(
+		dbm.NewDB("state", dbm.MemDBBackend, ""),
+		dbm.NewDB("lookup", dbm.MemDBBackend, ""),
+		dbm.NewDB("addrPreimage", dbm.MemDBBackend, ""),
+		dbm.NewDB("code", dbm.MemDBBackend, ""),
+		dbm.NewDB("trie", dbm.MemDBBackend, ""),
+	)
+	if err != nil {
+		t.Fatalf("NewDatabase: %v", err)
+	}
+
+	engineDb := stateethdb.New(dbm.NewDB("engine", dbm.MemDBBackend, ""))
+	engine := ethclique.New(genesis.Config.Clique, engineDb)
+
+	imp := &importer.Importer{
+		EthermintDB:    ethermintDB,
+		BlockchainFile: chainFile,
+		ChainConfig:    genesis.Config,
+		Engine:         engine,
+		Genesis:        genesis,
+	}
+
+	if err := imp.Run(importer.ImportConfig{}); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for height := uint64(1); height <= numBlocks; height++ {
+		if _, err := ethermintDB.VersionForHeight(height); err != nil {
+			t.Errorf("VersionForHeight(%d): %v", height, err)
+		}
+	}
+
+	// Tracing block #1 requires resolving the state just before it, i.e.
+	// genesis (height 0); ensureGenesis must have indexed that itself, since
+	// nothing else in Run ever records a height for the genesis block.
+	debugAPI := &evm.DebugAPI{
+		EthermintDB:    ethermintDB,
+		ChainConfig:    genesis.Config,
+		Engine:         engine,
+		BlockchainFile: chainFile,
+	}
+	if _, err := debugAPI.TraceBlockByNumber(1, nil); err != nil {
+		t.Errorf("TraceBlockByNumber(1): %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+package importer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/cosmos/ethermint/core"
+	"github.com/cosmos/ethermint/tracers"
+
+	ethmisc "github.com/ethereum/go-ethereum/consensus/misc"
+	ethcore "github.com/ethereum/go-ethereum/core"
+	ethstate "github.com/ethereum/go-ethereum/core/state"
+	ethtypes "github.com/ethereum/go-ethereum/core/types"
+	ethvm "github.com/ethereum/go-ethereum/core/vm"
+	ethrlp "github.com/ethereum/go-ethereum/rlp"
+)
+
+// decodedBlock pairs a decoded block with the error (if any) hit while
+// warming its transactions' sender cache ahead of time, recovered by a
+// validation worker so the committer's sequential hot path never pays for
+// the ECDSA recovery itself -- only the cache lookup go-ethereum's
+// types.Sender already memoizes per-transaction.
+type decodedBlock struct {
+	block *ethtypes.Block
+	err   error
+}
+
+// Run pipelines the import described by cfg. One goroutine does nothing
+// but decode RLP blocks off disk; cfg.Workers goroutines recover
+// transaction senders for those blocks (the only per-block work safe to
+// do out of order); and a single committer goroutine replays transactions
+// and commits state strictly in block order, periodically persisting a
+// Checkpoint and reporting Stats.
+//
+// This replaces the old Import, which did decoding, execution and ad hoc
+// diagnostics (a hard-coded trace tx hash, a structlogs.txt file, and two
+// printf'd root hashes) all on one goroutine with no way to resume.
+//
+// Note this only parallelizes sender recovery: transaction execution and
+// state commitment still happen one block at a time in commit, strictly
+// in order. ImportConfig.Workers speeds up the warm-up step ahead of that
+// sequential hot path; it does not make execution itself concurrent.
+func (imp *Importer) Run(cfg ImportConfig) error {
+	if err := imp.ensureGenesis(); err != nil {
+		return err
+	}
+
+	input, err := os.Open(imp.BlockchainFile)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	checkpoint, err := LoadCheckpoint(cfg.CheckpointFile)
+	if err != nil {
+		return err
+	}
+	// Checkpoint.Height is the last block actually committed, so resuming
+	// must start one past it; cfg.FromBlock only applies when there's no
+	// checkpoint yet to resume from.
+	fromBlock := cfg.FromBlock
+	if checkpoint.Height != 0 {
+		fromBlock = checkpoint.Height + 1
+	}
+
+	stream := ethrlp.NewStream(input, 0)
+	chainConfig := imp.chainConfig()
+	workers := cfg.workers()
+
+	// Each worker owns its own output channel so results can be consumed
+	// back in dispatch order without an explicit reorder buffer: the
+	// committer simply round-robins the same way blocks were dispatched.
+	// QueueDepth bounds the decoder->worker buffer as a whole, so it is
+	// split evenly across the per-worker input channels.
+	in := make([]chan *ethtypes.Block, workers)
+	out := make([]chan decodedBlock, workers)
+	for i := 0; i < workers; i++ {
+		in[i] = make(chan *ethtypes.Block, cfg.queueDepth()/workers)
+		out[i] = make(chan decodedBlock, cfg.queueDepth())
+	}
+
+	// done is closed once commit stops consuming out[], whether because it
+	// finished cleanly or returned early on error. Without it, the decoder
+	// and worker goroutines below can block forever sending into a full
+	// in[]/out[] channel that nobody will ever read again, and wg.Wait()
+	// hangs with them.
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func(i int) {
+			defer wg.Done()
+			defer close(out[i])
+			for block := range in[i] {
+				// Same signer ApplyTransaction derives for this block, so
+				// the cached sender is reused instead of recomputed.
+				err := recoverSenders(ethtypes.MakeSigner(chainConfig, block.Number()), block)
+				select {
+				case out[i] <- decodedBlock{block: block, err: err}:
+				case <-done:
+					return
+				}
+			}
+		}(i)
+	}
+
+	decodeErrCh := make(chan error, 1)
+	go func() {
+		defer func() {
+			for i := 0; i < workers; i++ {
+				close(in[i])
+			}
+		}()
+
+		var n int
+		for {
+			var block ethtypes.Block
+			if err := stream.Decode(&block); err == io.EOF {
+				decodeErrCh <- nil
+				return
+			} else if err != nil {
+				decodeErrCh <- fmt.Errorf("failed to decode block: %v", err)
+				return
+			}
+
+			if block.NumberU64() < fromBlock {
+				continue
+			}
+			if cfg.ToBlock != 0 && block.NumberU64() > cfg.ToBlock {
+				decodeErrCh <- nil
+				return
+			}
+
+			b := block
+			select {
+			case in[n%workers] <- &b:
+			case <-done:
+				decodeErrCh <- nil
+				return
+			}
+			n++
+		}
+	}()
+
+	commitErr := imp.commit(out, checkpoint, cfg)
+	close(done)
+	wg.Wait()
+
+	if decodeErr := <-decodeErrCh; commitErr == nil {
+		commitErr = decodeErr
+	}
+	return commitErr
+}
+
+// commit is the single sequential goroutine that actually executes
+// transactions and advances chain state; parallelism in Run only ever
+// covers sender recovery ahead of this point.
+func (imp *Importer) commit(out []chan decodedBlock, checkpoint Checkpoint, cfg ImportConfig) error {
+	imp.EthermintDB.CommitEvery = cfg.CommitEvery
+
+	chainConfig := imp.chainConfig()
+	chainContext := core.NewChainContext(chainConfig)
+	chainContext.SetEngine(imp.engine())
+
+	prevRoot := checkpoint.Root
+	lastHeight := checkpoint.Height
+
+	startTime := time.Now()
+	workers := len(out)
+	var processed, gasUsed uint64
+
+	for i := 0; ; i = (i + 1) % workers {
+		decoded, ok := <-out[i]
+		if !ok {
+			// All workers close their output in lockstep with the
+			// decoder finishing, so the first closed channel we see
+			// means every worker has drained.
+			break
+		}
+		if decoded.err != nil {
+			return decoded.err
+		}
+
+		select {
+		case interrupted := <-imp.InterruptCh:
+			if interrupted {
+				fmt.Println("interrupted, saving checkpoint...")
+				// prevRoot may only exist in EthermintDB's write-through
+				// cache so far; flush it before checkpointing or a
+				// resumed Run won't be able to resolve it via OpenTrie.
+				imp.EthermintDB.Flush()
+				return SaveCheckpoint(cfg.CheckpointFile, Checkpoint{Height: lastHeight, Root: prevRoot})
+			}
+		default:
+		}
+
+		block := decoded.block
+		header := block.Header()
+		chainContext.Coinbase = header.Coinbase
+		chainContext.SetHeader(block.NumberU64(), header)
+
+		gethStateDB, err := ethstate.New(prevRoot, imp.EthermintDB)
+		if err != nil {
+			return fmt.Errorf("failed to instantiate geth state.StateDB at block %d: %v", block.NumberU64(), err)
+		}
+
+		var (
+			receipts ethtypes.Receipts
+			usedGas  = new(uint64)
+			gp       = new(ethcore.GasPool).AddGas(block.GasLimit())
+		)
+
+		if chainConfig.DAOForkSupport && chainConfig.DAOForkBlock != nil && chainConfig.DAOForkBlock.Cmp(block.Number()) == 0 {
+			ethmisc.ApplyDAOHardFork(gethStateDB)
+		}
+
+		for txIdx, tx := range block.Transactions() {
+			gethStateDB.Prepare(tx.Hash(), block.Hash(), txIdx)
+
+			vmConfig := ethvm.Config{}
+			var tracer ethvm.Tracer
+			if cfg.shouldTrace(tx.Hash()) {
+				var err error
+				vmConfig, tracer, err = tracers.VMConfig(cfg.TraceConfig)
+				if err != nil {
+					return fmt.Errorf("at block %d, tx %x: %v", block.NumberU64(), tx.Hash(), err)
+				}
+			}
+
+			receipt, _, err := ethcore.ApplyTransaction(chainConfig, chainContext, nil, gp, gethStateDB, header, tx, usedGas, vmConfig)
+			if err != nil {
+				return fmt.Errorf("at block %d, tx %x: %v", block.NumberU64(), tx.Hash(), err)
+			}
+			receipts = append(receipts, receipt)
+
+			if tracer != nil {
+				failed := receipt.Status == ethtypes.ReceiptStatusFailed
+				if err := tracers.WriteResult(cfg.traceOut(), tracer, receipt.GasUsed, failed); err != nil {
+					return fmt.Errorf("at block %d, tx %x: failed to write trace: %v", block.NumberU64(), tx.Hash(), err)
+				}
+			}
+		}
+
+		// Finalize mints the block reward (or none, for Clique) and any
+		// other end-of-block consensus bookkeeping; this is what lets
+		// NewEngine's choice of Ethash vs Clique actually matter, instead
+		// of every chain getting the hard-coded mainnet PoW reward.
+		if _, err := chainContext.Engine().Finalize(chainContext, header, gethStateDB, block.Transactions(), block.Uncles(), receipts); err != nil {
+			return fmt.Errorf("at block %d: finalize: %v", block.NumberU64(), err)
+		}
+
+		prevRoot, err = gethStateDB.Commit(chainConfig.IsEIP158(block.Number()))
+		if err != nil {
+			return fmt.Errorf("at block %d: %v", block.NumberU64(), err)
+		}
+		imp.EthermintDB.Commit()
+		if err := imp.EthermintDB.RecordHeight(block.NumberU64(), prevRoot); err != nil {
+			return fmt.Errorf("at block %d: failed to index height: %v", block.NumberU64(), err)
+		}
+
+		lastHeight = block.NumberU64()
+		processed++
+		gasUsed += *usedGas
+
+		if cfg.CheckpointEvery != 0 && processed%cfg.CheckpointEvery == 0 {
+			// Same reasoning as the interrupt checkpoint above: prevRoot
+			// must actually be resolvable via lookupDb on resume, not
+			// just sitting in the cache.
+			imp.EthermintDB.Flush()
+			if err := SaveCheckpoint(cfg.CheckpointFile, Checkpoint{Height: lastHeight, Root: prevRoot}); err != nil {
+				return err
+			}
+		}
+
+		if cfg.Progress != nil && processed%cfg.reportEvery() == 0 {
+			elapsed := time.Since(startTime).Seconds()
+			var mem runtime.MemStats
+			runtime.ReadMemStats(&mem)
+			cacheStats := imp.EthermintDB.CacheMetrics()
+			cfg.Progress.Report(Stats{
+				Height:       lastHeight,
+				Root:         prevRoot,
+				BlocksTotal:  processed,
+				ElapsedSecs:  elapsed,
+				BlocksPerSec: float64(processed) / elapsed,
+				GasPerSec:    float64(gasUsed) / elapsed,
+				MGasPerSec:   float64(gasUsed) / elapsed / 1e6,
+				AllocBytes:   mem.Alloc,
+				CacheHits:    cacheStats.Hits,
+				CacheMisses:  cacheStats.Misses,
+			})
+		}
+	}
+
+	// Commit only flushes every CommitEvery calls; force the last, possibly
+	// still-cached batch out to stateStore now so the checkpoint below (and
+	// RecordHeight's pendingHeights) reflect what was actually persisted,
+	// not just what commit() believes it processed.
+	imp.EthermintDB.Flush()
+	return SaveCheckpoint(cfg.CheckpointFile, Checkpoint{Height: lastHeight, Root: prevRoot})
+}
+
+// recoverSenders warms the sender cache for every transaction in block so
+// the committer's ApplyTransaction calls hit the cache instead of doing
+// ECDSA recovery on the sequential hot path. Its return value only
+// matters for the error: types.Sender caches the recovered address on
+// the transaction itself, so the address returned here is never read.
+func recoverSenders(signer ethtypes.Signer, block *ethtypes.Block) error {
+	for _, tx := range block.Transactions() {
+		if _, err := ethtypes.Sender(signer, tx); err != nil {
+			return fmt.Errorf("failed to recover sender for tx %x in block %d: %v", tx.Hash(), block.NumberU64(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+// Package tracers turns an EVM replay into a structured trace: either the
+// built-in per-opcode struct logger, or one of go-ethereum's JS tracers
+// (callTracer, 4byteTracer, ...). It is the single place Importer and
+// x/evm's DebugAPI both go through to build an ethvm.Config and format
+// the result, replacing the importer's previous ad hoc setup: a
+// hard-coded trace tx hash, vmConfig.Debug toggled inline per
+// transaction, and a StructLogger instantiated but never read back.
+package tracers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethvm "github.com/ethereum/go-ethereum/core/vm"
+	ethtracers "github.com/ethereum/go-ethereum/eth/tracers"
+)
+
+// TraceConfig selects and configures the tracer a call to VMConfig builds.
+// It mirrors go-ethereum's debug_traceTransaction/debug_traceBlock RPC
+// config so a DebugAPI method can decode it straight off the wire.
+type TraceConfig struct {
+	*ethvm.LogConfig
+
+	// Tracer names a JS tracer registered with go-ethereum's eth/tracers
+	// (e.g. "callTracer", "4byteTracer"). Empty selects the built-in
+	// per-opcode StructLogger instead.
+	Tracer *string
+}
+
+// VMConfig builds the ethvm.Config and underlying ethvm.Tracer that cfg
+// describes, ready to hand to ethcore.ApplyTransaction. A nil cfg, like one
+// naming no tracer, selects the default per-opcode StructLogger (with
+// default log options when cfg carries none).
+func VMConfig(cfg *TraceConfig) (ethvm.Config, ethvm.Tracer, error) {
+	if cfg == nil {
+		cfg = &TraceConfig{}
+	}
+
+	if cfg.Tracer != nil && *cfg.Tracer != "" {
+		tracer, err := ethtracers.New(*cfg.Tracer)
+		if err != nil {
+			return ethvm.Config{}, nil, fmt.Errorf("failed to instantiate tracer %q: %v", *cfg.Tracer, err)
+		}
+		return ethvm.Config{Debug: true, Tracer: tracer}, tracer, nil
+	}
+
+	logConfig := cfg.LogConfig
+	if logConfig == nil {
+		logConfig = &ethvm.LogConfig{}
+	}
+	tracer := ethvm.NewStructLogger(logConfig)
+	return ethvm.Config{Debug: true, Tracer: tracer}, tracer, nil
+}
+
+// structLogResult is the StructLogger rendering of a trace, matching the
+// shape go-ethereum's debug_traceTransaction has always returned for the
+// default (non-JS) tracer.
+type structLogResult struct {
+	Gas         uint64            `json:"gas"`
+	Failed      bool              `json:"failed"`
+	ReturnValue string            `json:"returnValue"`
+	StructLogs  []ethvm.StructLog `json:"structLogs"`
+}
+
+// Result marshals tracer's accumulated state to JSON once the traced
+// transaction has finished executing. A StructLogger is rendered as
+// {gas, failed, returnValue, structLogs}, with failed set from the
+// transaction's actual outcome (its receipt status, since ApplyTransaction
+// already folds a reverted execution into a failed receipt rather than
+// returning an error); a JS tracer is asked for its own result via
+// GetResult, since callTracer/4byteTracer each define their own shape.
+func Result(tracer ethvm.Tracer, gasUsed uint64, failed bool, returnValue []byte) (json.RawMessage, error) {
+	switch t := tracer.(type) {
+	case *ethvm.StructLogger:
+		return json.Marshal(structLogResult{
+			Gas:         gasUsed,
+			Failed:      failed,
+			ReturnValue: fmt.Sprintf("%x", returnValue),
+			StructLogs:  t.StructLogs(),
+		})
+	case *ethtracers.Tracer:
+		return t.GetResult()
+	default:
+		return nil, fmt.Errorf("tracer %T does not support result formatting", tracer)
+	}
+}
+
+// WriteResult formats tracer's result for a transaction that ran with the
+// given gas usage and failure status and writes it to w as a single line
+// of JSON, for callers (like Importer) that stream traces straight to a
+// file or stdout instead of returning them to an RPC caller.
+func WriteResult(w io.Writer, tracer ethvm.Tracer, gasUsed uint64, failed bool) error {
+	result, err := Result(tracer, gasUsed, failed, nil)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(result, '\n'))
+	return err
+}
+
+// TxTraceResult is one transaction's entry in a debug_traceBlock*
+// response: its own trace, or the error that stopped it, matching
+// go-ethereum's eth/tracers.TxTraceResult.
+type TxTraceResult struct {
+	TxHash ethcommon.Hash  `json:"txHash"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
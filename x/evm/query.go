@@ -0,0 +1,29 @@
+// Package evm holds the query-side glue between the EVM's on-chain state
+// and JSON-RPC handlers that need to read it historically rather than
+// just at HEAD.
+package evm
+
+import (
+	"github.com/cosmos/ethermint/state"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+)
+
+// QueryStorageAt returns the raw value stored at key in addr's storage as
+// of blockNum, resolving blockNum to an IAVL version via
+// state.Database.VersionForHeight. This is what backs a historical
+// eth_getStorageAt / debug_storageRangeAt handler.
+func QueryStorageAt(db *state.Database, addr ethcommon.Address, key ethcommon.Hash, blockNum uint64) ([]byte, error) {
+	version, err := db.VersionForHeight(blockNum)
+	if err != nil {
+		return nil, err
+	}
+
+	addrHash := ethcrypto.Keccak256Hash(addr.Bytes())
+	trie, err := db.OpenStorageTrieAtVersion(addrHash, version)
+	if err != nil {
+		return nil, err
+	}
+	return trie.TryGet(key.Bytes())
+}